@@ -0,0 +1,34 @@
+package docker
+
+// AuthConfig holds the credentials used to authenticate against a single
+// registry host.
+type AuthConfig struct {
+	Username string
+	Password string
+}
+
+// AuthConfigs indexes AuthConfig by the normalized registry hostname it
+// applies to.
+type AuthConfigs struct {
+	Configs map[string]AuthConfig
+}
+
+// providerSetToRegistryAuth converts the "registry_auth" blocks from the
+// provider schema into an AuthConfigs lookup keyed by normalized hostname.
+func providerSetToRegistryAuth(raw []interface{}) (*AuthConfigs, error) {
+	authConfigs := &AuthConfigs{
+		Configs: make(map[string]AuthConfig),
+	}
+
+	for _, v := range raw {
+		authBlock := v.(map[string]interface{})
+		address := normalizeRegistryAddress(authBlock["address"].(string))
+
+		authConfigs.Configs[address] = AuthConfig{
+			Username: authBlock["username"].(string),
+			Password: authBlock["password"].(string),
+		}
+	}
+
+	return authConfigs, nil
+}
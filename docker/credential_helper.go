@@ -0,0 +1,53 @@
+package docker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// credentialHelperResponse is the JSON shape returned on stdout by a
+// docker-credential-* helper's "get" command.
+// See: https://github.com/docker/docker-credential-helpers
+type credentialHelperResponse struct {
+	ServerURL string
+	Username  string
+	Secret    string
+}
+
+// getCredentialsFromHelper shells out to "docker-credential-<suffix> get",
+// writing serverURL on stdin and parsing the {Username,Secret,ServerURL}
+// JSON reply on stdout, per the credential-helpers protocol.
+func getCredentialsFromHelper(suffix, serverURL string) (AuthConfig, bool, error) {
+	helper := "docker-credential-" + suffix
+
+	cmd := exec.Command(helper, "get")
+	cmd.Stdin = strings.NewReader(serverURL)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if isCredentialHelperNotFoundErr(stderr.String()) {
+			return AuthConfig{}, false, nil
+		}
+		return AuthConfig{}, false, fmt.Errorf("Error running %s: %s: %s", helper, err, strings.TrimSpace(stderr.String()))
+	}
+
+	var resp credentialHelperResponse
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return AuthConfig{}, false, fmt.Errorf("Error parsing %s response: %s", helper, err)
+	}
+
+	return AuthConfig{Username: resp.Username, Password: resp.Secret}, true, nil
+}
+
+// isCredentialHelperNotFoundErr detects the "credentials not found in native
+// keychain" message that helpers emit on stderr when asked for a server they
+// have no entry for, as opposed to a real execution failure.
+func isCredentialHelperNotFoundErr(stderr string) bool {
+	return strings.Contains(strings.ToLower(stderr), "credentials not found")
+}
@@ -2,16 +2,13 @@ package docker
 
 import (
 	"crypto/sha256"
-	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"log"
 	"net/http"
-	"net/url"
-	"os"
-	"strconv"
 	"strings"
-	"log"
+
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
 )
 
@@ -25,17 +22,98 @@ func dataSourceDockerRegistryImage() *schema.Resource {
 				Optional: true,
 			},
 
+			"platform": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    1,
+				Description: "Platform to resolve from a multi-arch manifest list or OCI image index. If unset, a multi-arch image resolves to the index digest itself",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"os": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						"architecture": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						"variant": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+					},
+				},
+			},
+
+			"auth_soft_fail": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "If true, do not fail the lookup when a credential helper or registry_auth_file is configured but has no entry for this registry",
+			},
+
 			"sha256_digest": {
 				Type:     schema.TypeString,
 				Computed: true,
 			},
+
+			"manifest_media_type": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"config_digest": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"architecture": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"os": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"created": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"labels": {
+				Type:     schema.TypeMap,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+
+			"layers": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"digest": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"size": {
+							Type:     schema.TypeInt,
+							Computed: true,
+						},
+					},
+				},
+			},
 		},
 	}
 }
 
 func dataSourceDockerRegistryImageRead(d *schema.ResourceData, meta interface{}) error {
 	pullOpts := parseImageOptions(d.Get("name").(string))
-	authConfig := meta.(*ProviderConfig).AuthConfigs
+	providerConfig := meta.(*ProviderConfig)
+	authConfig := providerConfig.AuthConfigs
+	authSoftFail := d.Get("auth_soft_fail").(bool)
+	platform := expandPlatform(d.Get("platform").([]interface{}))
 
 	// Use the official Docker Hub if a registry isn't specified
 	if pullOpts.Registry == "" {
@@ -59,178 +137,321 @@ func dataSourceDockerRegistryImageRead(d *schema.ResourceData, meta interface{})
 	username := ""
 	password := ""
 
-	if auth, ok := authConfig.Configs[normalizeRegistryAddress(pullOpts.Registry)]; ok {
+	registryHost := normalizeRegistryAddress(pullOpts.Registry)
+
+	if auth, ok := authConfig.Configs[registryHost]; ok {
 		username = auth.Username
 		password = auth.Password
+	} else if providerConfig.RegistryAuthFile != nil {
+		auth, err := resolveAuthFromConfigFile(providerConfig.RegistryAuthFile, registryHost)
+		if err != nil {
+			if _, notFound := err.(*errCredentialNotFound); !notFound || !authSoftFail {
+				return fmt.Errorf("Error resolving credentials for registry %s: %s", registryHost, err)
+			}
+		} else {
+			username = auth.Username
+			password = auth.Password
+		}
 	}
 
-	digest, err := getImageDigest(pullOpts.Registry, pullOpts.Repository, pullOpts.Tag, username, password, false)
-
+	info, err := fetchRegistryImageInfo(pullOpts.Registry, pullOpts.Repository, pullOpts.Tag, username, password, platform, providerConfig.RegistryCertsDir, providerConfig.InsecureRegistries)
 	if err != nil {
-		digest, err = getImageDigest(pullOpts.Registry, pullOpts.Repository, pullOpts.Tag, username, password, true)
-		if err != nil {
-			return fmt.Errorf("Got an error when attempting to fetch image version from registry: %s", err)
-		}
+		return fmt.Errorf("Got an error when attempting to fetch image version from registry: %s", err)
 	}
 
-	d.SetId(digest)
-	d.Set("sha256_digest", digest)
+	d.SetId(info.Digest)
+	d.Set("sha256_digest", info.Digest)
+	d.Set("manifest_media_type", info.ManifestMediaType)
+	d.Set("config_digest", info.ConfigDigest)
+	d.Set("architecture", info.Architecture)
+	d.Set("os", info.OS)
+	d.Set("created", info.Created)
+	d.Set("labels", info.Labels)
+	d.Set("layers", flattenManifestLayers(info.Layers))
 
 	return nil
 }
 
-func getImageDigest(registry, image, tag, username, password string, fallback bool) (string, error) {
-	client := http.DefaultClient
-
-	// Allow insecure registries only for ACC tests
-	// cuz we don't have a valid certs for this case
-	if env, okEnv := os.LookupEnv("TF_ACC"); okEnv {
-		if i, errConv := strconv.Atoi(env); errConv == nil && i >= 1 {
-			cfg := &tls.Config{
-				InsecureSkipVerify: true,
-			}
-			client.Transport = &http.Transport{
-				TLSClientConfig: cfg,
-			}
+func flattenManifestLayers(layers []manifestDescriptor) []map[string]interface{} {
+	flattened := make([]map[string]interface{}, len(layers))
+	for i, layer := range layers {
+		flattened[i] = map[string]interface{}{
+			"digest": layer.Digest,
+			"size":   layer.Size,
 		}
 	}
+	return flattened
+}
 
-	// Separate the base url from any pathing it 
-	// contains since path should come after 'v2'
-	separatedUrlArr := strings.Split(registry, "/")
-	baseUrl := separatedUrlArr[0];
-	path := ""
+// The result of resolving an image reference: digest plus manifest/config metadata
+type registryImageInfo struct {
+	Digest            string
+	ManifestMediaType string
+	ConfigDigest      string
+	Architecture      string
+	OS                string
+	Created           string
+	Labels            map[string]string
+	Layers            []manifestDescriptor
+}
 
-	if len(separatedUrlArr) > 1 {
-		path = strings.Join(separatedUrlArr[1:], "/")	
-		lastChar := string(path[len(path) - 1])
-		if lastChar != "/" {
-			path = path + "/"
-		}	
+// Tried in order: the modern v2/OCI set first, then the legacy v1 signed
+// manifest for registries (like old gcr.io) that don't support anything newer
+var manifestAcceptHeaders = [][]string{
+	{mediaTypeManifestV2, mediaTypeOCIManifest, mediaTypeManifestList, mediaTypeOCIImageIndex},
+	{mediaTypeManifestV1},
+}
+
+// Reads the single optional "platform" block, or nil if it wasn't set
+func expandPlatform(raw []interface{}) *manifestPlatform {
+	if len(raw) == 0 || raw[0] == nil {
+		return nil
 	}
 
-	queryAddress := "https://"+baseUrl+"/v2/"+path+image+"/manifests/"+tag
-	log.Println("[DEBUG] Getting manifest from: " + queryAddress)
-	
-	req, err := http.NewRequest("GET", "https://"+baseUrl+"/v2/"+path+image+"/manifests/"+tag, nil)
-	if err != nil {
-		return "", fmt.Errorf("Error creating registry request: %s", err)
+	block := raw[0].(map[string]interface{})
+	return &manifestPlatform{
+		OS:           block["os"].(string),
+		Architecture: block["architecture"].(string),
+		Variant:      block["variant"].(string),
+	}
+}
+
+// Resolves registry/image:tag to a digest and, for single-platform manifests,
+// the image's config metadata. A multi-arch index is matched against platform
+// when set; otherwise the index digest itself is returned with no further metadata.
+func fetchRegistryImageInfo(registry, image, tag, username, password string, platform *manifestPlatform, certsDir string, insecureRegistries []string) (*registryImageInfo, error) {
+	baseUrl, path := splitRegistryURL(registry)
+	repository := path + image
+	insecure := isInsecureRegistry(baseUrl, insecureRegistries)
+
+	log.Println("[DEBUG] Getting manifest from: " + "https://" + baseUrl + "/v2/" + repository + "/manifests/" + tag)
+
+	var resp *http.Response
+	var body []byte
+	var conn *registryConn
+	var err error
+
+	for _, accept := range manifestAcceptHeaders {
+		resp, body, conn, err = doRegistryGet(baseUrl, repository, "manifests/"+tag, username, password, accept, certsDir, insecure)
+		if err == nil && resp.StatusCode == http.StatusOK {
+			break
+		}
 	}
-	
-	log.Println("[DEBUG] Username: %s | Password: %s", username, password)
-	if username != "" {
-		req.SetBasicAuth(username, password)
+	if err != nil {
+		return nil, err
 	}
 
-	// Set this header so that we get the v2 manifest back from the registry.
-	req.Header.Set("Accept", "application/vnd.docker.distribution.manifest.v2+json")
-	if fallback {
-		// Fallback to this header if the registry does not support the v2 manifest like gcr.io
-		req.Header.Set("Accept", "application/vnd.docker.distribution.manifest.v1+prettyjws")
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Got bad response from registry after attempting query: %s/%s - %s", baseUrl, repository, resp.Status)
 	}
 
-	resp, err := client.Do(req)
+	mediaType := strings.TrimSpace(strings.SplitN(resp.Header.Get("Content-Type"), ";", 2)[0])
+	digest := digestFromResponse(resp, body)
 
-	if err != nil {
-		return "", fmt.Errorf("Error during registry request: %s", err)
-	}
+	if isManifestIndexMediaType(mediaType) {
+		var index manifestIndex
+		if err := json.Unmarshal(body, &index); err != nil {
+			return nil, fmt.Errorf("Error parsing manifest index: %s", err)
+		}
 
-	switch resp.StatusCode {
-	// Basic auth was valid or not needed
-	case http.StatusOK:
-		return getDigestFromResponse(resp)
+		if platform == nil {
+			return &registryImageInfo{Digest: digest, ManifestMediaType: mediaType}, nil
+		}
 
-	// Either OAuth is required or the basic auth creds were invalid
-	case http.StatusUnauthorized:
-		if strings.HasPrefix(resp.Header.Get("www-authenticate"), "Bearer") {
-			auth := parseAuthHeader(resp.Header.Get("www-authenticate"))
-			params := url.Values{}
-			params.Set("service", auth["service"])
-			params.Set("scope", auth["scope"])
-			tokenRequest, err := http.NewRequest("GET", auth["realm"]+"?"+params.Encode(), nil)
+		match, err := selectManifestForPlatform(index.Manifests, platform)
+		if err != nil {
+			return nil, err
+		}
 
-			if err != nil {
-				return "", fmt.Errorf("Error creating registry request: %s", err)
-			}
+		resp, body, err = conn.get(repository, "manifests/"+match.Digest, []string{match.MediaType})
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("Got bad response from registry after attempting query: %s/%s - %s", baseUrl, repository, resp.Status)
+		}
 
-			if username != "" {
-				tokenRequest.SetBasicAuth(username, password)
-			}
+		mediaType = match.MediaType
+		digest = match.Digest
+	}
 
-			tokenResponse, err := client.Do(tokenRequest)
+	info := &registryImageInfo{Digest: digest, ManifestMediaType: mediaType}
 
-			if err != nil {
-				return "", fmt.Errorf("Error during registry request: %s", err)
-			}
+	var manifest imageManifest
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		// A body that doesn't parse into this shape yields the digest
+		// without the additional metadata rather than failing.
+		return info, nil
+	}
 
-			if tokenResponse.StatusCode != http.StatusOK {
-				return "", fmt.Errorf("Got bad response from registry after attempting query: %s - " + tokenResponse.Status, queryAddress)
-			}
+	// Legacy v1 manifests parse but carry no config descriptor
+	if manifest.Config.Digest == "" {
+		return info, nil
+	}
 
-			body, err := ioutil.ReadAll(tokenResponse.Body)
-			if err != nil {
-				return "", fmt.Errorf("Error reading response body: %s", err)
-			}
+	info.ConfigDigest = manifest.Config.Digest
+	info.Layers = manifest.Layers
 
-			token := &TokenResponse{}
-			err = json.Unmarshal(body, token)
-			if err != nil {
-				return "", fmt.Errorf("Error parsing OAuth token response: %s", err)
-			}
+	_, configBody, err := conn.get(repository, "blobs/"+manifest.Config.Digest, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Error fetching image config blob: %s", err)
+	}
 
-			req.Header.Set("Authorization", "Bearer "+token.Token)
-			digestResponse, err := client.Do(req)
+	var config imageConfig
+	if err := json.Unmarshal(configBody, &config); err != nil {
+		return nil, fmt.Errorf("Error parsing image config blob: %s", err)
+	}
 
-			if err != nil {
-				return "", fmt.Errorf("Error during registry request: %s", err)
-			}
+	info.Architecture = config.Architecture
+	info.OS = config.OS
+	info.Created = config.Created
+	info.Labels = config.Config.Labels
 
-			if digestResponse.StatusCode != http.StatusOK {
-				return "", fmt.Errorf("Got bad response from registry after attempting query: %s - " + digestResponse.Status, queryAddress)
-			}
+	return info, nil
+}
 
-			return getDigestFromResponse(digestResponse)
+// Finds the descriptor whose platform matches the requested os/architecture/variant
+func selectManifestForPlatform(manifests []manifestDescriptor, platform *manifestPlatform) (*manifestDescriptor, error) {
+	for i := range manifests {
+		p := manifests[i].Platform
+		if p == nil || p.OS != platform.OS || p.Architecture != platform.Architecture {
+			continue
 		}
+		if platform.Variant != "" && p.Variant != platform.Variant {
+			continue
+		}
+		return &manifests[i], nil
+	}
+
+	return nil, fmt.Errorf("No manifest found for platform %s/%s", platform.OS, platform.Architecture)
+}
 
-		return "", fmt.Errorf("Bad credentials: " + resp.Status)
+// Separates the registry host from any path prefix it carries, e.g. a
+// self-hosted registry mounted under a subpath
+func splitRegistryURL(registry string) (baseUrl, path string) {
+	parts := strings.Split(registry, "/")
+	baseUrl = parts[0]
 
-		// Some unexpected status was given, return an error
-	default:
-		return "", fmt.Errorf("Got bad response from registry after attempting query: %s - " + resp.Status, queryAddress)
+	if len(parts) > 1 {
+		path = strings.Join(parts[1:], "/")
+		if !strings.HasSuffix(path, "/") {
+			path = path + "/"
+		}
 	}
+
+	return baseUrl, path
 }
 
-type TokenResponse struct {
-	Token string
+// An already-authenticated registry client and base URL, returned by
+// doRegistryGet so later requests against the same repository (a
+// platform-specific manifest refetch, the config blob) can reuse the same
+// transport instead of re-pinging and re-authenticating from scratch.
+type registryConn struct {
+	client  *http.Client
+	baseURL string
 }
 
-// Parses key/value pairs from a WWW-Authenticate header
-func parseAuthHeader(header string) map[string]string {
-	parts := strings.SplitN(header, " ", 2)
-	parts = strings.Split(parts[1], ",")
-	opts := make(map[string]string)
+func (c *registryConn) get(repository, resourcePath string, accept []string) (*http.Response, []byte, error) {
+	req, err := http.NewRequest("GET", c.baseURL+"/v2/"+repository+"/"+resourcePath, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error creating registry request: %s", err)
+	}
+
+	if len(accept) > 0 {
+		req.Header.Set("Accept", strings.Join(accept, ", "))
+	}
 
-	for _, part := range parts {
-		vals := strings.SplitN(part, "=", 2)
-		key := vals[0]
-		val := strings.Trim(vals[1], "\", ")
-		opts[key] = val
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error during registry request: %s", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error reading registry response body: %s", err)
 	}
 
-	return opts
+	return resp, body, nil
 }
 
-func getDigestFromResponse(response *http.Response) (string, error) {
-	header := response.Header.Get("Docker-Content-Digest")
+// GETs <repository>/<resourcePath> from baseUrl, retrying over client certs
+// if a registry not marked insecure demands mutual TLS, and falling back to
+// plain HTTP if baseUrl is insecure and HTTPS fails outright.
+func doRegistryGet(baseUrl, repository, resourcePath, username, password string, accept []string, certsDir string, insecure bool) (*http.Response, []byte, *registryConn, error) {
+	resp, body, conn, err := doRegistryGetWithScheme("https", baseUrl, repository, resourcePath, username, password, accept, certsDir, insecure)
+	if err != nil && insecure {
+		// Try plain HTTP once HTTPS fails against a registry marked insecure
+		resp, body, conn, err = doRegistryGetWithScheme("http", baseUrl, repository, resourcePath, username, password, accept, certsDir, insecure)
+	}
+	return resp, body, conn, err
+}
 
-	if header == "" {
-		body, err := ioutil.ReadAll(response.Body)
-		if err != nil {
-			return "", fmt.Errorf("Error reading registry response body: %s", err)
+func doRegistryGetWithScheme(scheme, baseUrl, repository, resourcePath, username, password string, accept []string, certsDir string, insecure bool) (*http.Response, []byte, *registryConn, error) {
+	baseURL := scheme + "://" + baseUrl
+
+	baseClient, err := newRegistryHTTPClient(baseUrl, certsDir, insecure)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	resp, body, conn, err := doRegistryRequest(baseClient.Transport, baseURL, repository, resourcePath, username, password, accept)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if insecure || (resp.StatusCode != http.StatusForbidden && resp.StatusCode < http.StatusInternalServerError) {
+		return resp, body, conn, nil
+	}
+
+	// Retry with each available client certificate before giving up
+	pairs, certErr := loadRegistryCertPairs(hostCertDir(certsDir, baseUrl))
+	if certErr != nil {
+		return resp, body, conn, nil
+	}
+
+	for _, pair := range pairs {
+		certClient, certErr := newRegistryHTTPClientWithCert(baseUrl, certsDir, pair)
+		if certErr != nil {
+			continue
+		}
+
+		certResp, certBody, certConn, certErr := doRegistryRequest(certClient.Transport, baseURL, repository, resourcePath, username, password, accept)
+		if certErr != nil {
+			continue
+		}
+
+		resp, body, conn = certResp, certBody, certConn
+		if resp.StatusCode != http.StatusForbidden && resp.StatusCode < http.StatusInternalServerError {
+			break
 		}
+	}
+
+	return resp, body, conn, nil
+}
+
+func doRegistryRequest(base http.RoundTripper, baseURL, repository, resourcePath, username, password string, accept []string) (*http.Response, []byte, *registryConn, error) {
+	authTransport, err := newAuthenticatedRegistryTransport(base, baseURL, repository, username, password)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	conn := &registryConn{client: &http.Client{Transport: authTransport}, baseURL: baseURL}
+
+	resp, body, err := conn.get(repository, resourcePath, accept)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return resp, body, conn, nil
+}
 
-		return fmt.Sprintf("sha256:%x", sha256.Sum256(body)), nil
+// Prefers the registry-reported Docker-Content-Digest header, falling back
+// to hashing the body ourselves when it's absent
+func digestFromResponse(resp *http.Response, body []byte) string {
+	if header := resp.Header.Get("Docker-Content-Digest"); header != "" {
+		return header
 	}
 
-	return header, nil
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(body))
 }
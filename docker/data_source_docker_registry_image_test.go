@@ -0,0 +1,45 @@
+package docker
+
+import "testing"
+
+func TestExpandPlatform(t *testing.T) {
+	if got := expandPlatform(nil); got != nil {
+		t.Errorf("expandPlatform(nil) = %+v, want nil", got)
+	}
+
+	raw := []interface{}{
+		map[string]interface{}{"os": "linux", "architecture": "arm64", "variant": "v8"},
+	}
+	got := expandPlatform(raw)
+	if got == nil || got.OS != "linux" || got.Architecture != "arm64" || got.Variant != "v8" {
+		t.Errorf("expandPlatform(%+v) = %+v, want {OS:linux Architecture:arm64 Variant:v8}", raw, got)
+	}
+}
+
+func TestSelectManifestForPlatform(t *testing.T) {
+	manifests := []manifestDescriptor{
+		{Digest: "sha256:amd64", Platform: &manifestPlatform{OS: "linux", Architecture: "amd64"}},
+		{Digest: "sha256:arm-v7", Platform: &manifestPlatform{OS: "linux", Architecture: "arm", Variant: "v7"}},
+		{Digest: "sha256:arm-v8", Platform: &manifestPlatform{OS: "linux", Architecture: "arm", Variant: "v8"}},
+	}
+
+	match, err := selectManifestForPlatform(manifests, &manifestPlatform{OS: "linux", Architecture: "amd64"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if match.Digest != "sha256:amd64" {
+		t.Errorf("got digest %q, want sha256:amd64", match.Digest)
+	}
+
+	match, err = selectManifestForPlatform(manifests, &manifestPlatform{OS: "linux", Architecture: "arm", Variant: "v8"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if match.Digest != "sha256:arm-v8" {
+		t.Errorf("got digest %q, want sha256:arm-v8", match.Digest)
+	}
+
+	if _, err := selectManifestForPlatform(manifests, &manifestPlatform{OS: "windows", Architecture: "amd64"}); err == nil {
+		t.Error("expected error for unmatched platform, got nil")
+	}
+}
@@ -0,0 +1,49 @@
+package docker
+
+import "strings"
+
+// pullImageOptions holds the registry, repository and tag parsed out of a
+// Docker image reference such as "registry.internal:5000/org/app:v1".
+type pullImageOptions struct {
+	Registry   string
+	Repository string
+	Tag        string
+}
+
+// parseImageOptions splits an image reference of the form
+// "[registry/]repository[:tag]" into its component parts. The registry is
+// only considered present when the first path segment looks like a host
+// (contains a '.', a ':', or is literally "localhost").
+func parseImageOptions(image string) pullImageOptions {
+	opts := pullImageOptions{
+		Repository: image,
+	}
+
+	parts := strings.SplitN(image, "/", 2)
+	if len(parts) == 2 && looksLikeRegistryHost(parts[0]) {
+		opts.Registry = parts[0]
+		opts.Repository = parts[1]
+	}
+
+	if i := strings.LastIndex(opts.Repository, ":"); i != -1 {
+		opts.Tag = opts.Repository[i+1:]
+		opts.Repository = opts.Repository[:i]
+	}
+
+	return opts
+}
+
+func looksLikeRegistryHost(s string) bool {
+	return strings.Contains(s, ".") || strings.Contains(s, ":") || s == "localhost"
+}
+
+// normalizeRegistryAddress maps the well-known Docker Hub aliases onto the
+// hostname used for both authentication lookups and registry API calls.
+func normalizeRegistryAddress(address string) string {
+	switch address {
+	case "", "docker.io", "index.docker.io":
+		return "registry.hub.docker.com"
+	default:
+		return address
+	}
+}
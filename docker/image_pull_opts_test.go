@@ -0,0 +1,42 @@
+package docker
+
+import "testing"
+
+func TestParseImageOptions(t *testing.T) {
+	cases := []struct {
+		image      string
+		registry   string
+		repository string
+		tag        string
+	}{
+		{"consul", "", "consul", ""},
+		{"consul:1.9.0", "", "consul", "1.9.0"},
+		{"registry.internal:5000/org/app", "registry.internal:5000", "org/app", ""},
+		{"registry.internal:5000/org/app:v1", "registry.internal:5000", "org/app", "v1"},
+		{"localhost/org/app:v1", "localhost", "org/app", "v1"},
+		{"gcr.io/project/app", "gcr.io", "project/app", ""},
+	}
+
+	for _, c := range cases {
+		opts := parseImageOptions(c.image)
+		if opts.Registry != c.registry || opts.Repository != c.repository || opts.Tag != c.tag {
+			t.Errorf("parseImageOptions(%q) = %+v, want {Registry:%q Repository:%q Tag:%q}", c.image, opts, c.registry, c.repository, c.tag)
+		}
+	}
+}
+
+func TestNormalizeRegistryAddress(t *testing.T) {
+	cases := map[string]string{
+		"":                       "registry.hub.docker.com",
+		"docker.io":              "registry.hub.docker.com",
+		"index.docker.io":        "registry.hub.docker.com",
+		"registry.internal:5000": "registry.internal:5000",
+		"gcr.io":                 "gcr.io",
+	}
+
+	for in, want := range cases {
+		if got := normalizeRegistryAddress(in); got != want {
+			t.Errorf("normalizeRegistryAddress(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
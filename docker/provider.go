@@ -0,0 +1,102 @@
+package docker
+
+import (
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+)
+
+// Provider returns the top-level *schema.Provider for this plugin.
+func Provider() *schema.Provider {
+	return &schema.Provider{
+		Schema: map[string]*schema.Schema{
+			"registry_auth": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"address": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "Address of the registry",
+						},
+						"username": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "Username for the registry",
+						},
+						"password": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Sensitive:   true,
+							Description: "Password for the registry",
+						},
+					},
+				},
+			},
+
+			"registry_auth_file": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Path to a Docker config.json/auth.json file used to resolve registry credentials, including credsStore and credHelpers entries",
+			},
+
+			"registry_certs_dir": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "/etc/docker/certs.d",
+				Description: "Base directory containing per-registry CA and client certificates, laid out as <dir>/<registry host>/{*.crt,*.cert,*.key}, matching Docker's own certs.d convention",
+			},
+
+			"insecure_registries": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "List of host[:port] registries to skip TLS verification for, falling back to plain HTTP if HTTPS fails, matching Docker's own insecure registry handling",
+			},
+		},
+
+		DataSourcesMap: map[string]*schema.Resource{
+			"docker_registry_image": dataSourceDockerRegistryImage(),
+		},
+
+		ConfigureFunc: providerConfigure,
+	}
+}
+
+// Per-invocation state shared by this provider's resources and data sources
+type ProviderConfig struct {
+	AuthConfigs        *AuthConfigs
+	RegistryAuthFile   *dockerConfigFile
+	RegistryCertsDir   string
+	InsecureRegistries []string
+}
+
+func providerConfigure(d *schema.ResourceData) (interface{}, error) {
+	authConfigs, err := providerSetToRegistryAuth(d.Get("registry_auth").([]interface{}))
+	if err != nil {
+		return nil, err
+	}
+
+	config := &ProviderConfig{
+		AuthConfigs:        authConfigs,
+		RegistryCertsDir:   d.Get("registry_certs_dir").(string),
+		InsecureRegistries: stringListFromSchema(d.Get("insecure_registries").([]interface{})),
+	}
+
+	if path, ok := d.GetOk("registry_auth_file"); ok {
+		authFile, err := loadAuthConfigFile(path.(string))
+		if err != nil {
+			return nil, err
+		}
+		config.RegistryAuthFile = authFile
+	}
+
+	return config, nil
+}
+
+func stringListFromSchema(raw []interface{}) []string {
+	list := make([]string, len(raw))
+	for i, v := range raw {
+		list[i] = v.(string)
+	}
+	return list
+}
@@ -0,0 +1,124 @@
+package docker
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// dockerConfigAuth is a single entry in an auth.json/config.json "auths" map.
+type dockerConfigAuth struct {
+	Auth string `json:"auth"`
+}
+
+// dockerConfigFile mirrors the subset of Docker's config.json that is
+// relevant to resolving registry credentials: static base64 "auths" entries,
+// a global "credsStore" helper, and per-registry "credHelpers" overrides.
+type dockerConfigFile struct {
+	Auths       map[string]dockerConfigAuth `json:"auths"`
+	CredsStore  string                      `json:"credsStore"`
+	CredHelpers map[string]string           `json:"credHelpers"`
+}
+
+// loadAuthConfigFile reads and parses a static auth.json/config.json file.
+func loadAuthConfigFile(path string) (*dockerConfigFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading registry auth file %s: %s", path, err)
+	}
+
+	cfg := &dockerConfigFile{}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("Error parsing registry auth file %s: %s", path, err)
+	}
+
+	return cfg, nil
+}
+
+// errCredentialNotFound signals that the auth file yielded no credentials for
+// a registry: a pinned credHelper had no entry for it, or neither the
+// credsStore nor a static "auths" entry had one.
+type errCredentialNotFound struct {
+	registry string
+}
+
+func (e *errCredentialNotFound) Error() string {
+	return fmt.Sprintf("No credentials found for registry %s", e.registry)
+}
+
+// dockerHubConfigKeys are the keys Docker itself writes to config.json for
+// the official Docker Hub, none of which match our internal
+// "registry.hub.docker.com" normalization used for registry_auth blocks.
+var dockerHubConfigKeys = []string{"https://index.docker.io/v1/", "index.docker.io", "docker.io"}
+
+// registryAuthFileKeys returns the config.json keys to try for registry, in
+// order, including the Docker Hub aliases when registry is our normalized
+// Hub hostname.
+func registryAuthFileKeys(registry string) []string {
+	if registry != "registry.hub.docker.com" {
+		return []string{registry}
+	}
+	return append([]string{registry}, dockerHubConfigKeys...)
+}
+
+// resolveAuthFromConfigFile looks up credentials for registry in cfg,
+// preferring a per-registry credHelper, then the global credsStore, then a
+// static base64 "auths" entry. Docker Hub is tried under both our
+// normalized hostname and the aliases Docker itself writes to config.json.
+func resolveAuthFromConfigFile(cfg *dockerConfigFile, registry string) (AuthConfig, error) {
+	keys := registryAuthFileKeys(registry)
+
+	for _, key := range keys {
+		suffix, ok := cfg.CredHelpers[key]
+		if !ok {
+			continue
+		}
+
+		// A credHelper pinned to this registry is authoritative: a "not
+		// found" response from it must not fall through to credsStore or a
+		// static auths entry meant for some other lookup.
+		auth, found, err := getCredentialsFromHelper(suffix, key)
+		if err != nil {
+			return AuthConfig{}, err
+		}
+		if !found {
+			return AuthConfig{}, &errCredentialNotFound{registry: registry}
+		}
+		return auth, nil
+	}
+
+	if cfg.CredsStore != "" {
+		for _, key := range keys {
+			auth, found, err := getCredentialsFromHelper(cfg.CredsStore, key)
+			if err != nil {
+				return AuthConfig{}, err
+			}
+			if found {
+				return auth, nil
+			}
+		}
+	}
+
+	for _, key := range keys {
+		entry, ok := cfg.Auths[key]
+		if !ok {
+			continue
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+		if err != nil {
+			return AuthConfig{}, fmt.Errorf("Error decoding auth entry for %s: %s", key, err)
+		}
+
+		parts := strings.SplitN(string(decoded), ":", 2)
+		auth := AuthConfig{Username: parts[0]}
+		if len(parts) == 2 {
+			auth.Password = parts[1]
+		}
+		return auth, nil
+	}
+
+	return AuthConfig{}, &errCredentialNotFound{registry: registry}
+}
@@ -0,0 +1,84 @@
+package docker
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveAuthFromConfigFileStaticAuths(t *testing.T) {
+	cfg := &dockerConfigFile{
+		Auths: map[string]dockerConfigAuth{
+			// echo -n admin:hunter2 | base64
+			"registry.internal:5000": {Auth: "YWRtaW46aHVudGVyMg=="},
+		},
+	}
+
+	auth, err := resolveAuthFromConfigFile(cfg, "registry.internal:5000")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if auth.Username != "admin" || auth.Password != "hunter2" {
+		t.Errorf("got %+v, want Username=admin Password=hunter2", auth)
+	}
+}
+
+func TestResolveAuthFromConfigFileDockerHubAlias(t *testing.T) {
+	cfg := &dockerConfigFile{
+		Auths: map[string]dockerConfigAuth{
+			// Docker itself keys Hub credentials by this legacy v1 URL, not
+			// our normalized "registry.hub.docker.com".
+			"https://index.docker.io/v1/": {Auth: "YWRtaW46aHVudGVyMg=="},
+		},
+	}
+
+	auth, err := resolveAuthFromConfigFile(cfg, "registry.hub.docker.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if auth.Username != "admin" {
+		t.Errorf("got username %q, want admin", auth.Username)
+	}
+}
+
+func TestResolveAuthFromConfigFileCredHelperNotFoundDoesNotFallThrough(t *testing.T) {
+	dir, err := ioutil.TempDir("", "credhelper")
+	if err != nil {
+		t.Fatalf("TempDir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	helper := filepath.Join(dir, "docker-credential-stub")
+	script := "#!/bin/sh\necho 'credentials not found in native keychain' >&2\nexit 1\n"
+	if err := ioutil.WriteFile(helper, []byte(script), 0700); err != nil {
+		t.Fatalf("WriteFile: %s", err)
+	}
+
+	oldPath := os.Getenv("PATH")
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+oldPath)
+	defer os.Setenv("PATH", oldPath)
+
+	cfg := &dockerConfigFile{
+		CredHelpers: map[string]string{"registry.internal:5000": "stub"},
+		Auths: map[string]dockerConfigAuth{
+			// Must not be used: the credHelper pinned to this registry is
+			// authoritative, even though it reports no credentials found.
+			"registry.internal:5000": {Auth: "YWRtaW46aHVudGVyMg=="},
+		},
+	}
+
+	_, err = resolveAuthFromConfigFile(cfg, "registry.internal:5000")
+	if _, ok := err.(*errCredentialNotFound); !ok {
+		t.Errorf("got error %v, want *errCredentialNotFound", err)
+	}
+}
+
+func TestResolveAuthFromConfigFileNotFound(t *testing.T) {
+	cfg := &dockerConfigFile{Auths: map[string]dockerConfigAuth{}}
+
+	_, err := resolveAuthFromConfigFile(cfg, "registry.internal:5000")
+	if _, ok := err.(*errCredentialNotFound); !ok {
+		t.Errorf("got error %v, want *errCredentialNotFound", err)
+	}
+}
@@ -0,0 +1,54 @@
+package docker
+
+const (
+	mediaTypeManifestV2    = "application/vnd.docker.distribution.manifest.v2+json"
+	mediaTypeManifestV1    = "application/vnd.docker.distribution.manifest.v1+prettyjws"
+	mediaTypeManifestList  = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaTypeOCIManifest   = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeOCIImageIndex = "application/vnd.oci.image.index.v1+json"
+)
+
+// A content-addressable pointer to a manifest, config, or layer blob
+type manifestDescriptor struct {
+	MediaType string             `json:"mediaType"`
+	Digest    string             `json:"digest"`
+	Size      int64              `json:"size"`
+	Platform  *manifestPlatform  `json:"platform,omitempty"`
+}
+
+// The OS/architecture/variant a manifest in a multi-arch index was built for
+type manifestPlatform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+// A single-platform Docker v2 / OCI image manifest
+type imageManifest struct {
+	SchemaVersion int                   `json:"schemaVersion"`
+	MediaType     string                `json:"mediaType"`
+	Config        manifestDescriptor    `json:"config"`
+	Layers        []manifestDescriptor  `json:"layers"`
+}
+
+// A Docker manifest list / OCI image index: per-platform manifest descriptors
+type manifestIndex struct {
+	SchemaVersion int                   `json:"schemaVersion"`
+	MediaType     string                `json:"mediaType"`
+	Manifests     []manifestDescriptor  `json:"manifests"`
+}
+
+// Whether mediaType is a manifest list (Docker) or image index (OCI)
+func isManifestIndexMediaType(mediaType string) bool {
+	return mediaType == mediaTypeManifestList || mediaType == mediaTypeOCIImageIndex
+}
+
+// The subset of the image config blob that's surfaced by the data source
+type imageConfig struct {
+	Architecture string    `json:"architecture"`
+	OS           string    `json:"os"`
+	Created      string    `json:"created"`
+	Config       struct {
+		Labels map[string]string `json:"Labels"`
+	} `json:"config"`
+}
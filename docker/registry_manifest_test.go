@@ -0,0 +1,18 @@
+package docker
+
+import "testing"
+
+func TestIsManifestIndexMediaType(t *testing.T) {
+	cases := map[string]bool{
+		mediaTypeManifestList:  true,
+		mediaTypeOCIImageIndex: true,
+		mediaTypeManifestV2:    false,
+		mediaTypeOCIManifest:   false,
+		mediaTypeManifestV1:    false,
+	}
+	for mediaType, want := range cases {
+		if got := isManifestIndexMediaType(mediaType); got != want {
+			t.Errorf("isManifestIndexMediaType(%q) = %v, want %v", mediaType, got, want)
+		}
+	}
+}
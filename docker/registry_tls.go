@@ -0,0 +1,144 @@
+package docker
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// A client certificate/key pair found in a registry's certs.d directory
+type registryCertPair struct {
+	Name string
+	Cert string
+	Key  string
+}
+
+func hostCertDir(baseDir, hostname string) string {
+	return filepath.Join(baseDir, hostname)
+}
+
+// Returns nil (use the default system pool) if dir has no "*.crt" files to add
+func loadRegistryCertPool(dir string) (*x509.CertPool, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, nil
+	}
+
+	var crts []string
+	for _, f := range files {
+		if strings.HasSuffix(f.Name(), ".crt") {
+			crts = append(crts, f.Name())
+		}
+	}
+
+	if len(crts) == 0 {
+		return nil, nil
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+
+	for _, name := range crts {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("Error reading registry CA cert %s: %s", name, err)
+		}
+
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("Error parsing registry CA cert %s", name)
+		}
+	}
+
+	return pool, nil
+}
+
+// Matched "<name>.cert"/"<name>.key" pairs in dir, sorted alphabetically by name
+func loadRegistryCertPairs(dir string) ([]registryCertPair, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, nil
+	}
+
+	names := make(map[string]bool)
+	for _, f := range files {
+		if strings.HasSuffix(f.Name(), ".cert") {
+			names[strings.TrimSuffix(f.Name(), ".cert")] = true
+		}
+	}
+
+	var pairs []registryCertPair
+	for name := range names {
+		keyPath := filepath.Join(dir, name+".key")
+		if _, err := ioutil.ReadFile(keyPath); err != nil {
+			continue
+		}
+
+		pairs = append(pairs, registryCertPair{
+			Name: name,
+			Cert: filepath.Join(dir, name+".cert"),
+			Key:  keyPath,
+		})
+	}
+
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].Name < pairs[j].Name })
+
+	return pairs, nil
+}
+
+func (p registryCertPair) loadTLSCertificate() (tls.Certificate, error) {
+	return tls.LoadX509KeyPair(p.Cert, p.Key)
+}
+
+func isInsecureRegistry(host string, insecureRegistries []string) bool {
+	for _, r := range insecureRegistries {
+		if r == host {
+			return true
+		}
+	}
+	return false
+}
+
+func newRegistryHTTPClient(hostname, certsDir string, insecureSkipVerify bool) (*http.Client, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: insecureSkipVerify}
+
+	if !insecureSkipVerify {
+		pool, err := loadRegistryCertPool(hostCertDir(certsDir, hostname))
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return &http.Client{
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+	}, nil
+}
+
+// Like newRegistryHTTPClient but also presents a client cert for mutual TLS
+func newRegistryHTTPClientWithCert(hostname, certsDir string, pair registryCertPair) (*http.Client, error) {
+	cert, err := pair.loadTLSCertificate()
+	if err != nil {
+		return nil, fmt.Errorf("Error loading registry client certificate %s: %s", pair.Name, err)
+	}
+
+	pool, err := loadRegistryCertPool(hostCertDir(certsDir, hostname))
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				RootCAs:      pool,
+				Certificates: []tls.Certificate{cert},
+			},
+		},
+	}, nil
+}
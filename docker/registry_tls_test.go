@@ -0,0 +1,50 @@
+package docker
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadRegistryCertPairs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "certs.d")
+	if err != nil {
+		t.Fatalf("TempDir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("x"), 0600); err != nil {
+			t.Fatalf("WriteFile %s: %s", name, err)
+		}
+	}
+
+	write("b.cert")
+	write("b.key")
+	write("a.cert")
+	write("a.key")
+	write("orphan.cert") // no matching key, should be skipped
+
+	pairs, err := loadRegistryCertPairs(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(pairs) != 2 {
+		t.Fatalf("got %d pairs, want 2: %+v", len(pairs), pairs)
+	}
+	if pairs[0].Name != "a" || pairs[1].Name != "b" {
+		t.Errorf("got pairs in order %q, %q; want alphabetical a, b", pairs[0].Name, pairs[1].Name)
+	}
+}
+
+func TestLoadRegistryCertPairsMissingDir(t *testing.T) {
+	pairs, err := loadRegistryCertPairs("/no/such/dir")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if pairs != nil {
+		t.Errorf("got %+v, want nil", pairs)
+	}
+}